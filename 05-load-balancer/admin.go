@@ -0,0 +1,282 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// BackendFactory builds Backends from a URL and weight using the load
+// balancer's process-wide configuration, so the admin API and config reload
+// can add backends identically to how -backends does at startup.
+type BackendFactory struct {
+	HealthConfigs              map[string]HealthCheckConfig
+	DefaultHealthCheckInterval time.Duration
+	FastCGIRoot                string
+	FastCGISplitPath           []string
+}
+
+// Build constructs a Backend for serverURL from spec, wiring up its
+// HealthConfig, circuit breaker defaults, and Transport (FastCGI or HTTP,
+// chosen by scheme). For fastcgi:// backends, spec.FastCGIRoot /
+// spec.FastCGISplitPath override the factory's process-wide defaults, so
+// different FastCGI upstreams (e.g. separate PHP-FPM pools) can use
+// different DOCUMENT_ROOTs.
+func (f *BackendFactory) Build(serverURL *url.URL, spec BackendSpec) *Backend {
+	weight := spec.Weight
+	if weight <= 0 {
+		weight = 1
+	}
+
+	healthConfig, ok := f.HealthConfigs[serverURL.String()]
+	if !ok {
+		healthConfig = DefaultHealthCheckConfig()
+		healthConfig.Interval = f.DefaultHealthCheckInterval
+	}
+
+	// ForceAttemptHTTP2 lets HTTPTransport negotiate HTTP/2 with TLS backends via ALPN.
+	reverseProxy := &http.Client{
+		Timeout:   30 * time.Second,
+		Transport: &http.Transport{ForceAttemptHTTP2: true},
+	}
+	backend := &Backend{
+		URL:          serverURL,
+		Alive:        true,
+		Weight:       weight,
+		HealthConfig: healthConfig,
+		CBConfig:     DefaultCircuitBreakerConfig(),
+		ReverseProxy: reverseProxy,
+	}
+
+	if serverURL.Scheme == "fastcgi" {
+		root := spec.FastCGIRoot
+		if root == "" {
+			root = f.FastCGIRoot
+		}
+		splitPath := spec.FastCGISplitPath
+		if len(splitPath) == 0 {
+			splitPath = f.FastCGISplitPath
+		}
+		backend.Transport = NewFastCGITransport(FastCGIConfig{
+			Network:   "tcp",
+			Addr:      serverURL.Host,
+			Root:      root,
+			SplitPath: splitPath,
+			Timeout:   30 * time.Second,
+		})
+	} else {
+		backend.Transport = NewHTTPTransport(reverseProxy)
+	}
+
+	return backend
+}
+
+// BackendSpec is the JSON shape of one backend in a -config file and in the
+// admin API's POST /admin/backends request body. FastCGIRoot and
+// FastCGISplitPath are optional per-backend overrides of the -fastcgi-root
+// and -fastcgi-split-path flags, only meaningful for fastcgi:// backends.
+type BackendSpec struct {
+	URL              string   `json:"url"`
+	Weight           int      `json:"weight,omitempty"`
+	FastCGIRoot      string   `json:"fastcgiRoot,omitempty"`
+	FastCGISplitPath []string `json:"fastcgiSplitPath,omitempty"`
+}
+
+// loadBackendSpecs reads a JSON array of BackendSpec from path.
+func loadBackendSpecs(path string) ([]BackendSpec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading backend config: %w", err)
+	}
+	var specs []BackendSpec
+	if err := json.Unmarshal(data, &specs); err != nil {
+		return nil, fmt.Errorf("parsing backend config: %w", err)
+	}
+	return specs, nil
+}
+
+// reloadBackends re-reads path and diffs its desired backend list against
+// the pool's current one, adding new backends, removing ones no longer
+// listed, and updating the weight of ones that remain. Errors are logged
+// and otherwise non-fatal, since a bad reload shouldn't take down a running
+// load balancer.
+func reloadBackends(ctx context.Context, s *ServerPool, factory *BackendFactory, path string) {
+	specs, err := loadBackendSpecs(path)
+	if err != nil {
+		log.Printf("Config reload failed: %v", err)
+		return
+	}
+
+	desired := make(map[string]BackendSpec, len(specs))
+	for _, spec := range specs {
+		if spec.Weight <= 0 {
+			spec.Weight = 1
+		}
+		desired[spec.URL] = spec
+	}
+
+	current := make(map[string]*Backend)
+	for _, b := range s.Backends() {
+		current[b.URL.String()] = b
+	}
+
+	for urlStr, spec := range desired {
+		if existing, ok := current[urlStr]; ok {
+			if existing.Weight != spec.Weight {
+				existing.Weight = spec.Weight
+				log.Printf("Config reload: updated weight for %s to %d", urlStr, spec.Weight)
+			}
+			continue
+		}
+
+		serverURL, err := url.Parse(urlStr)
+		if err != nil {
+			log.Printf("Config reload: skipping invalid backend url %q: %v", urlStr, err)
+			continue
+		}
+		backend := factory.Build(serverURL, spec)
+		backend.RecordHealthResult(checkBackendHealth(backend))
+		s.AddBackend(backend)
+		startBackendLifecycle(ctx, backend, s.Metrics)
+		log.Printf("Config reload: added backend %s (weight=%d)", urlStr, spec.Weight)
+	}
+
+	for urlStr := range current {
+		if _, ok := desired[urlStr]; !ok {
+			s.RemoveBackend(urlStr)
+			log.Printf("Config reload: removed backend %s", urlStr)
+		}
+	}
+}
+
+// checkAdminAuth enforces the admin API's bearer token. The admin API is
+// disabled entirely (404) unless a token is configured via -admin-token, so
+// it can't be exposed unauthenticated by accident.
+func checkAdminAuth(w http.ResponseWriter, r *http.Request, token string) bool {
+	if token == "" {
+		http.NotFound(w, r)
+		return false
+	}
+	const prefix = "Bearer "
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, prefix) || subtle.ConstantTimeCompare([]byte(auth[len(prefix):]), []byte(token)) != 1 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// writeAdminJSON writes v to w as indented JSON, matching statusHandler's format.
+func writeAdminJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	body, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		http.Error(w, "Error encoding response", http.StatusInternalServerError)
+		log.Printf("Error encoding admin response: %v", err)
+		return
+	}
+	w.Write(body)
+	w.Write([]byte("\n"))
+}
+
+// adminBackendsHandler serves GET (list) and POST (add) on /admin/backends.
+func adminBackendsHandler(w http.ResponseWriter, r *http.Request, s *ServerPool, factory *BackendFactory, ctx context.Context, token string) {
+	if !checkAdminAuth(w, r, token) {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeAdminJSON(w, map[string]interface{}{"backends": s.GetBackendStatus()})
+
+	case http.MethodPost:
+		var spec BackendSpec
+		if err := json.NewDecoder(r.Body).Decode(&spec); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if spec.URL == "" {
+			http.Error(w, "url is required", http.StatusBadRequest)
+			return
+		}
+		if s.FindBackend(spec.URL) != nil {
+			http.Error(w, "Backend already exists", http.StatusConflict)
+			return
+		}
+		serverURL, err := url.Parse(spec.URL)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Invalid backend url: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		backend := factory.Build(serverURL, spec)
+		backend.RecordHealthResult(checkBackendHealth(backend))
+		s.AddBackend(backend)
+		startBackendLifecycle(ctx, backend, s.Metrics)
+		log.Printf("Admin API: added backend %s (weight=%d)", serverURL, backend.Weight)
+
+		w.WriteHeader(http.StatusCreated)
+		writeAdminJSON(w, backendStatus(backend))
+
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// adminBackendHandler serves DELETE (remove) and POST .../drain on
+// /admin/backends/{id}, where {id} is the backend's URL-encoded URL string.
+func adminBackendHandler(w http.ResponseWriter, r *http.Request, s *ServerPool, token string) {
+	if !checkAdminAuth(w, r, token) {
+		return
+	}
+
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin/backends/"), "/")
+	drain := strings.HasSuffix(rest, "/drain")
+	if drain {
+		rest = strings.TrimSuffix(rest, "drain")
+		rest = strings.Trim(rest, "/")
+	}
+	if rest == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	id, err := url.PathUnescape(rest)
+	if err != nil {
+		http.Error(w, "Invalid backend id", http.StatusBadRequest)
+		return
+	}
+
+	backend := s.FindBackend(id)
+	if backend == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch {
+	case drain && r.Method == http.MethodPost:
+		// Draining stops GetNextPeer from selecting this backend for new
+		// requests; ActiveConns reports how many in-flight requests are
+		// still being allowed to finish on their own.
+		backend.Drain()
+		log.Printf("Admin API: draining backend %s (active connections: %d)", id, backend.ActiveConns())
+		writeAdminJSON(w, backendStatus(backend))
+
+	case !drain && r.Method == http.MethodDelete:
+		s.RemoveBackend(id)
+		log.Printf("Admin API: removed backend %s", id)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		w.Header().Set("Allow", "DELETE, POST")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+// CircuitState is the state of a backend's passive-health circuit breaker.
+type CircuitState int32
+
+const (
+	// CircuitClosed is the normal state: requests flow and outcomes are tracked.
+	CircuitClosed CircuitState = iota
+	// CircuitOpen means the backend is ejected from rotation until Cooldown elapses.
+	CircuitOpen
+	// CircuitHalfOpen admits a single probe request to test recovery before closing.
+	CircuitHalfOpen
+)
+
+// String returns a human-readable name for the circuit state, e.g. for /lb-status.
+func (c CircuitState) String() string {
+	switch c {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// CircuitBreakerConfig controls when a backend is passively ejected based on
+// real request outcomes, as opposed to the active HealthCheckConfig probes.
+type CircuitBreakerConfig struct {
+	// FailureThreshold is the fraction of failing requests (0.0-1.0) in the
+	// current window that trips the circuit open.
+	FailureThreshold float64
+	// MinRequests is the minimum number of requests observed in the window
+	// before the failure rate is evaluated, to avoid tripping on small samples.
+	MinRequests int64
+	// WindowDuration is how often the rolling request/failure counters reset.
+	WindowDuration time.Duration
+	// Cooldown is how long the circuit stays Open before allowing a HalfOpen probe.
+	Cooldown time.Duration
+}
+
+// DefaultCircuitBreakerConfig returns conservative passive health check defaults.
+func DefaultCircuitBreakerConfig() CircuitBreakerConfig {
+	return CircuitBreakerConfig{
+		FailureThreshold: 0.5,
+		MinRequests:      10,
+		WindowDuration:   10 * time.Second,
+		Cooldown:         30 * time.Second,
+	}
+}
+
+// RecordOutcome updates the backend's passive health counters with the
+// result of one proxied request and evaluates the circuit breaker's state
+// machine. It must be called once per request, after the upstream call
+// completes (or fails/times out).
+func (b *Backend) RecordOutcome(success bool, latency time.Duration) {
+	atomic.AddInt64(&b.requestsTotal, 1)
+	atomic.AddInt64(&b.windowRequests, 1)
+	if !success {
+		atomic.AddInt64(&b.failuresTotal, 1)
+		atomic.AddInt64(&b.windowFailures, 1)
+	}
+	b.updateLatencyEWMA(latency)
+
+	switch b.circuitStateNow() {
+	case CircuitHalfOpen:
+		b.releaseProbe()
+		if success {
+			b.closeCircuit()
+		} else {
+			b.openCircuit()
+		}
+	case CircuitClosed:
+		requests := atomic.LoadInt64(&b.windowRequests)
+		failures := atomic.LoadInt64(&b.windowFailures)
+		if requests >= b.CBConfig.MinRequests && float64(failures)/float64(requests) >= b.CBConfig.FailureThreshold {
+			b.openCircuit()
+		}
+	}
+}
+
+// updateLatencyEWMA folds latency into an exponentially weighted moving
+// average of response time, smoothing out single-request spikes.
+func (b *Backend) updateLatencyEWMA(latency time.Duration) {
+	const alpha = 0.2
+	sample := float64(latency.Milliseconds())
+
+	for {
+		oldBits := atomic.LoadUint64(&b.latencyEWMABits)
+		old := math.Float64frombits(oldBits)
+		var next float64
+		if old == 0 {
+			next = sample
+		} else {
+			next = alpha*sample + (1-alpha)*old
+		}
+		if atomic.CompareAndSwapUint64(&b.latencyEWMABits, oldBits, math.Float64bits(next)) {
+			return
+		}
+	}
+}
+
+// LatencyEWMA returns the backend's exponentially weighted moving average
+// response time in milliseconds.
+func (b *Backend) LatencyEWMA() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&b.latencyEWMABits))
+}
+
+// circuitStateNow returns the backend's current circuit state, lazily
+// transitioning Open to HalfOpen once Cooldown has elapsed since ejection.
+func (b *Backend) circuitStateNow() CircuitState {
+	state := CircuitState(atomic.LoadInt32(&b.circuitState))
+	if state != CircuitOpen {
+		return state
+	}
+
+	ejectedAt := time.Unix(0, atomic.LoadInt64(&b.lastEjectedAtUnixNano))
+	if time.Since(ejectedAt) < b.CBConfig.Cooldown {
+		return CircuitOpen
+	}
+
+	atomic.CompareAndSwapInt32(&b.circuitState, int32(CircuitOpen), int32(CircuitHalfOpen))
+	return CircuitState(atomic.LoadInt32(&b.circuitState))
+}
+
+// openCircuit ejects the backend from rotation and records the ejection.
+func (b *Backend) openCircuit() {
+	if atomic.SwapInt32(&b.circuitState, int32(CircuitOpen)) != int32(CircuitOpen) {
+		atomic.AddInt64(&b.ejectionsTotal, 1)
+	}
+	atomic.StoreInt64(&b.lastEjectedAtUnixNano, time.Now().UnixNano())
+	atomic.StoreInt32(&b.halfOpenProbeInFlight, 0)
+}
+
+// closeCircuit restores the backend to normal rotation and resets its window.
+func (b *Backend) closeCircuit() {
+	atomic.StoreInt32(&b.circuitState, int32(CircuitClosed))
+	atomic.StoreInt64(&b.windowRequests, 0)
+	atomic.StoreInt64(&b.windowFailures, 0)
+	atomic.StoreInt32(&b.halfOpenProbeInFlight, 0)
+}
+
+// resetWindow clears the rolling request/failure counters used to evaluate
+// the failure-rate threshold, called periodically so old traffic ages out.
+func (b *Backend) resetWindow() {
+	if b.circuitStateNow() == CircuitClosed {
+		atomic.StoreInt64(&b.windowRequests, 0)
+		atomic.StoreInt64(&b.windowFailures, 0)
+	}
+}
+
+// tryAcquireProbe admits a single in-flight request while the circuit is
+// HalfOpen, so only one probe tests recovery at a time.
+func (b *Backend) tryAcquireProbe() bool {
+	return atomic.CompareAndSwapInt32(&b.halfOpenProbeInFlight, 0, 1)
+}
+
+// releaseProbe frees the HalfOpen probe slot after the probe request completes.
+func (b *Backend) releaseProbe() {
+	atomic.StoreInt32(&b.halfOpenProbeInFlight, 0)
+}
+
+// Available reports whether the backend is a candidate for routing: actively
+// healthy, not draining, and not ejected by the circuit breaker. It never
+// consumes the HalfOpen recovery probe (a HalfOpen backend is reported
+// available with its probe slot untouched; the only state it may change is
+// circuitStateNow's lazy Open-to-HalfOpen move), so it's safe to call from
+// filtering code that doesn't guarantee the backend it's called on is the one that ends up
+// handling a real request — e.g. policy candidate filtering or the /metrics
+// gauge. To actually admit a request to a HalfOpen backend, call TryDispatch
+// on the one backend chosen to handle it.
+func (b *Backend) Available() bool {
+	if !b.IsAlive() || b.IsDraining() {
+		return false
+	}
+	switch b.circuitStateNow() {
+	case CircuitClosed, CircuitHalfOpen:
+		return true
+	default:
+		return false
+	}
+}
+
+// TryDispatch admits one real request to the backend, consuming the HalfOpen
+// circuit's single recovery probe slot if applicable. Call this exactly once,
+// immediately before dispatching to the backend GetNextPeer chose, and only
+// proceed with the request if it returns true.
+func (b *Backend) TryDispatch() bool {
+	if !b.IsAlive() || b.IsDraining() {
+		return false
+	}
+	switch b.circuitStateNow() {
+	case CircuitClosed:
+		return true
+	case CircuitHalfOpen:
+		return b.tryAcquireProbe()
+	default:
+		return false
+	}
+}
+
+// CircuitMetrics is a point-in-time snapshot of a backend's passive health
+// counters, used to render /lb-status.
+type CircuitMetrics struct {
+	State          string
+	RequestsTotal  int64
+	FailuresTotal  int64
+	EjectionsTotal int64
+	LastEjectedAt  time.Time
+	LatencyEWMAMs  float64
+}
+
+// circuitWindowResetRoutine periodically ages out a backend's rolling
+// request/failure counters so the failure-rate threshold reflects only
+// recent traffic rather than accumulating since startup.
+func circuitWindowResetRoutine(ctx context.Context, b *Backend) {
+	ticker := time.NewTicker(b.CBConfig.WindowDuration)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			b.resetWindow()
+		}
+	}
+}
+
+// Metrics returns a snapshot of the backend's passive health counters.
+func (b *Backend) Metrics() CircuitMetrics {
+	var lastEjected time.Time
+	if nano := atomic.LoadInt64(&b.lastEjectedAtUnixNano); nano != 0 {
+		lastEjected = time.Unix(0, nano)
+	}
+	return CircuitMetrics{
+		State:          b.circuitStateNow().String(),
+		RequestsTotal:  atomic.LoadInt64(&b.requestsTotal),
+		FailuresTotal:  atomic.LoadInt64(&b.failuresTotal),
+		EjectionsTotal: atomic.LoadInt64(&b.ejectionsTotal),
+		LastEjectedAt:  lastEjected,
+		LatencyEWMAMs:  b.LatencyEWMA(),
+	}
+}
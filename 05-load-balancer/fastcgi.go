@@ -0,0 +1,413 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/textproto"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FastCGI record types, as defined by the FastCGI 1.0 specification.
+const (
+	fcgiBeginRequest = 1
+	fcgiAbortRequest = 2
+	fcgiEndRequest   = 3
+	fcgiParams       = 4
+	fcgiStdin        = 5
+	fcgiStdout       = 6
+	fcgiStderr       = 7
+)
+
+const (
+	fcgiRoleResponder = 1
+
+	fcgiKeepConn = 1
+
+	fcgiVersion1 = 1
+
+	fcgiMaxContentLength = 0xfffe // leave room so length + padding fit in a record
+)
+
+// FastCGIConfig configures how a backend's URL and incoming requests are
+// translated into FastCGI records for a PHP-FPM / Python FCGI upstream.
+type FastCGIConfig struct {
+	// Network and Addr identify the FastCGI endpoint, e.g. "tcp", "host:port".
+	Network string
+	Addr    string
+
+	// Root is sent as DOCUMENT_ROOT and prefixed onto SCRIPT_FILENAME.
+	Root string
+
+	// SplitPath marks path-info boundaries (e.g. []string{".php"}): the first
+	// matching marker in the request path splits SCRIPT_NAME from PATH_INFO,
+	// mirroring how PHP-FPM resolves "/index.php/extra/path-info".
+	SplitPath []string
+
+	// Timeout bounds how long a single FastCGI round trip may take.
+	Timeout time.Duration
+}
+
+// FastCGITransport speaks the FastCGI protocol to a single upstream,
+// reusing pooled keep-alive connections. Each connection carries one
+// request at a time; requests are not multiplexed on a connection by
+// request ID.
+type FastCGITransport struct {
+	cfg FastCGIConfig
+
+	mu        sync.Mutex
+	idle      []net.Conn
+	nextReqID uint16
+}
+
+// NewFastCGITransport creates a FastCGITransport for the given config.
+func NewFastCGITransport(cfg FastCGIConfig) *FastCGITransport {
+	return &FastCGITransport{cfg: cfg}
+}
+
+// RoundTrip translates req into a FastCGI request, sends it to the
+// configured upstream, and reassembles the FastCGI response into an
+// *http.Response.
+func (t *FastCGITransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	conn, err := t.acquireConn()
+	if err != nil {
+		return nil, fmt.Errorf("fastcgi: dial %s: %w", t.cfg.Addr, err)
+	}
+
+	if t.cfg.Timeout > 0 {
+		conn.SetDeadline(time.Now().Add(t.cfg.Timeout))
+	}
+
+	reqID := t.nextRequestID()
+
+	if err := writeBeginRequest(conn, reqID, fcgiRoleResponder, fcgiKeepConn); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("fastcgi: begin request: %w", err)
+	}
+	if err := writeParams(conn, reqID, buildParams(req, t.cfg)); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("fastcgi: write params: %w", err)
+	}
+	if err := writeStdin(conn, reqID, req.Body); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("fastcgi: write stdin: %w", err)
+	}
+
+	resp, keepConn, err := readResponse(conn, reqID, req, fcgiKeepConn != 0)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("fastcgi: read response: %w", err)
+	}
+
+	if keepConn {
+		conn.SetDeadline(time.Time{})
+		t.releaseConn(conn)
+	} else {
+		conn.Close()
+	}
+	return resp, nil
+}
+
+// acquireConn takes an idle pooled connection or dials a new one.
+func (t *FastCGITransport) acquireConn() (net.Conn, error) {
+	t.mu.Lock()
+	if n := len(t.idle); n > 0 {
+		conn := t.idle[n-1]
+		t.idle = t.idle[:n-1]
+		t.mu.Unlock()
+		return conn, nil
+	}
+	t.mu.Unlock()
+
+	network := t.cfg.Network
+	if network == "" {
+		network = "tcp"
+	}
+	return net.DialTimeout(network, t.cfg.Addr, 5*time.Second)
+}
+
+// releaseConn returns a still-usable connection to the pool for reuse.
+func (t *FastCGITransport) releaseConn(conn net.Conn) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.idle = append(t.idle, conn)
+}
+
+// nextRequestID returns the next FastCGI request ID, cycling through the
+// 16-bit ID space and skipping the reserved 0 value.
+func (t *FastCGITransport) nextRequestID() uint16 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.nextReqID++
+	if t.nextReqID == 0 {
+		t.nextReqID = 1
+	}
+	return t.nextReqID
+}
+
+// fcgiHeader is the 8-byte record header prefixing every FastCGI record.
+type fcgiHeader struct {
+	Version       uint8
+	Type          uint8
+	RequestID     uint16
+	ContentLength uint16
+	PaddingLength uint8
+	Reserved      uint8
+}
+
+// writeRecord writes a single FastCGI record of the given type and content,
+// padding the content to a multiple of 8 bytes as recommended by the spec.
+// Empty content produces a single zero-length record.
+func writeRecord(w io.Writer, recType uint8, reqID uint16, content []byte) error {
+	for {
+		chunk := content
+		if len(chunk) > fcgiMaxContentLength {
+			chunk = chunk[:fcgiMaxContentLength]
+		}
+		content = content[len(chunk):]
+
+		padding := (8 - len(chunk)%8) % 8
+		header := fcgiHeader{
+			Version:       fcgiVersion1,
+			Type:          recType,
+			RequestID:     reqID,
+			ContentLength: uint16(len(chunk)),
+			PaddingLength: uint8(padding),
+		}
+		if err := binary.Write(w, binary.BigEndian, header); err != nil {
+			return err
+		}
+		if _, err := w.Write(chunk); err != nil {
+			return err
+		}
+		if padding > 0 {
+			if _, err := w.Write(make([]byte, padding)); err != nil {
+				return err
+			}
+		}
+		if len(content) == 0 {
+			return nil
+		}
+	}
+}
+
+// writeBeginRequest sends the FCGI_BEGIN_REQUEST record that opens a request.
+func writeBeginRequest(w io.Writer, reqID uint16, role uint16, flags uint8) error {
+	body := make([]byte, 8)
+	binary.BigEndian.PutUint16(body[0:2], role)
+	body[2] = flags
+	return writeRecord(w, fcgiBeginRequest, reqID, body)
+}
+
+// writeParams encodes params as FastCGI name-value pairs in one or more
+// FCGI_PARAMS records, terminated by the required empty record.
+func writeParams(w io.Writer, reqID uint16, params map[string]string) error {
+	var buf bytes.Buffer
+	for _, k := range sortedKeys(params) {
+		writeNameValuePair(&buf, k, params[k])
+	}
+	if err := writeRecord(w, fcgiParams, reqID, buf.Bytes()); err != nil {
+		return err
+	}
+	return writeRecord(w, fcgiParams, reqID, nil) // empty record terminates PARAMS
+}
+
+// writeStdin streams body as FCGI_STDIN records, terminated by an empty record.
+func writeStdin(w io.Writer, reqID uint16, body io.Reader) error {
+	if body != nil {
+		buf := make([]byte, 32*1024)
+		for {
+			n, err := body.Read(buf)
+			if n > 0 {
+				if werr := writeRecord(w, fcgiStdin, reqID, buf[:n]); werr != nil {
+					return werr
+				}
+			}
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return writeRecord(w, fcgiStdin, reqID, nil) // empty record terminates STDIN
+}
+
+// writeNameValuePair appends one FastCGI name-value pair using the
+// spec's variable-length size encoding (1 byte if <128, else 4 bytes).
+func writeNameValuePair(buf *bytes.Buffer, name, value string) {
+	writeSize(buf, len(name))
+	writeSize(buf, len(value))
+	buf.WriteString(name)
+	buf.WriteString(value)
+}
+
+func writeSize(buf *bytes.Buffer, size int) {
+	if size < 128 {
+		buf.WriteByte(byte(size))
+		return
+	}
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, uint32(size)|0x80000000)
+	buf.Write(b)
+}
+
+// readResponse reads FCGI_STDOUT/FCGI_STDERR records until FCGI_END_REQUEST,
+// reassembling stdout into a CGI-style response (headers, blank line, body)
+// and parsing it into an *http.Response. keepConnRequested is whatever keep-conn
+// flag the request's BEGIN_REQUEST was sent with; the returned bool reports
+// whether the connection is actually safe to reuse, which also requires the
+// FastCGI application to have reported a normal FCGI_REQUEST_COMPLETE status.
+func readResponse(conn net.Conn, reqID uint16, req *http.Request, keepConnRequested bool) (*http.Response, bool, error) {
+	var stdout, stderr bytes.Buffer
+
+	for {
+		var header fcgiHeader
+		if err := binary.Read(conn, binary.BigEndian, &header); err != nil {
+			return nil, false, err
+		}
+		content := make([]byte, header.ContentLength)
+		if _, err := io.ReadFull(conn, content); err != nil {
+			return nil, false, err
+		}
+		if header.PaddingLength > 0 {
+			if _, err := io.CopyN(io.Discard, conn, int64(header.PaddingLength)); err != nil {
+				return nil, false, err
+			}
+		}
+		if header.RequestID != reqID {
+			continue // belongs to another multiplexed request; not expected with a dedicated conn
+		}
+
+		switch header.Type {
+		case fcgiStdout:
+			stdout.Write(content)
+		case fcgiStderr:
+			stderr.Write(content)
+		case fcgiEndRequest:
+			if stderr.Len() > 0 {
+				logFastCGIStderr(req, stderr.String())
+			}
+			var endBody struct {
+				AppStatus      uint32
+				ProtocolStatus uint8
+				Reserved       [3]byte
+			}
+			if err := binary.Read(bytes.NewReader(content), binary.BigEndian, &endBody); err != nil {
+				return nil, false, fmt.Errorf("parsing END_REQUEST: %w", err)
+			}
+			resp, err := parseCGIResponse(stdout.Bytes(), req)
+			return resp, keepConnRequested && endBody.ProtocolStatus == 0, err
+		}
+	}
+}
+
+// parseCGIResponse parses the CGI-style output a FastCGI application writes
+// to stdout: a block of "Name: value" header lines, a blank line, then the
+// response body. A "Status: 404 Not Found" header sets the response code.
+func parseCGIResponse(stdout []byte, req *http.Request) (*http.Response, error) {
+	reader := textproto.NewReader(bufio.NewReader(bytes.NewReader(stdout)))
+	mimeHeader, err := reader.ReadMIMEHeader()
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("parsing CGI headers: %w", err)
+	}
+
+	statusCode := http.StatusOK
+	if status := mimeHeader.Get("Status"); status != "" {
+		if code, convErr := strconv.Atoi(strings.Fields(status)[0]); convErr == nil {
+			statusCode = code
+		}
+		mimeHeader.Del("Status")
+	}
+
+	body, err := io.ReadAll(reader.R)
+	if err != nil {
+		return nil, fmt.Errorf("reading CGI body: %w", err)
+	}
+
+	return &http.Response{
+		StatusCode: statusCode,
+		Status:     fmt.Sprintf("%d %s", statusCode, http.StatusText(statusCode)),
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header(mimeHeader),
+		Body:       io.NopCloser(bytes.NewReader(body)),
+		Request:    req,
+	}, nil
+}
+
+// logFastCGIStderr surfaces FCGI_STDERR output from the upstream application
+// alongside the request it belongs to, the way the rest of this package logs.
+func logFastCGIStderr(req *http.Request, stderr string) {
+	log.Printf("FastCGI stderr for %s %s: %s", req.Method, req.URL.Path, strings.TrimSpace(stderr))
+}
+
+// buildParams assembles the CGI/FastCGI environment variables for req.
+func buildParams(req *http.Request, cfg FastCGIConfig) map[string]string {
+	scriptName, pathInfo := splitScriptPath(req.URL.Path, cfg.SplitPath)
+
+	params := map[string]string{
+		"GATEWAY_INTERFACE": "CGI/1.1",
+		"SERVER_PROTOCOL":   req.Proto,
+		"SERVER_SOFTWARE":   "go-load-balancer",
+		"REQUEST_METHOD":    req.Method,
+		"REQUEST_URI":       req.URL.RequestURI(),
+		"QUERY_STRING":      req.URL.RawQuery,
+		"SCRIPT_NAME":       scriptName,
+		"PATH_INFO":         pathInfo,
+		"SCRIPT_FILENAME":   cfg.Root + scriptName,
+		"DOCUMENT_ROOT":     cfg.Root,
+		"SERVER_NAME":       req.URL.Hostname(),
+		"CONTENT_TYPE":      req.Header.Get("Content-Type"),
+	}
+	if req.ContentLength > 0 {
+		params["CONTENT_LENGTH"] = strconv.FormatInt(req.ContentLength, 10)
+	} else if cl := req.Header.Get("Content-Length"); cl != "" {
+		params["CONTENT_LENGTH"] = cl
+	}
+
+	for key, values := range req.Header {
+		if key == "Content-Type" || key == "Content-Length" {
+			continue // already sent as CONTENT_TYPE / CONTENT_LENGTH
+		}
+		envKey := "HTTP_" + strings.ToUpper(strings.ReplaceAll(key, "-", "_"))
+		params[envKey] = strings.Join(values, ", ")
+	}
+
+	return params
+}
+
+// splitScriptPath splits a request path into SCRIPT_NAME and PATH_INFO at
+// the first occurrence of any marker in splitPath (e.g. ".php"), mirroring
+// how PHP-FPM resolves URLs like "/index.php/extra/path-info". With no
+// markers configured, the whole path is the script and PATH_INFO is empty.
+func splitScriptPath(path string, splitPath []string) (scriptName, pathInfo string) {
+	for _, marker := range splitPath {
+		if idx := strings.Index(path, marker); idx != -1 {
+			cut := idx + len(marker)
+			return path[:cut], path[cut:]
+		}
+	}
+	return path, ""
+}
+
+// sortedKeys returns m's keys in sorted order, for deterministic PARAMS encoding.
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"regexp"
+	"time"
+)
+
+// HealthCheckConfig controls how a single backend's active health check is performed.
+type HealthCheckConfig struct {
+	Path               string        `json:"path"`
+	Interval           time.Duration `json:"-"`
+	IntervalSeconds    int           `json:"intervalSeconds"`
+	Timeout            time.Duration `json:"-"`
+	TimeoutSeconds     int           `json:"timeoutSeconds"`
+	ExpectedStatus     int           `json:"expectedStatus"`
+	BodyRegex          string        `json:"bodyRegex"`
+	HealthyThreshold   int           `json:"healthyThreshold"`
+	UnhealthyThreshold int           `json:"unhealthyThreshold"`
+
+	bodyRE *regexp.Regexp
+}
+
+// DefaultHealthCheckConfig returns the health check settings used when no
+// per-backend override is present: probe "/health", fall back to treating
+// any non-5xx response as healthy, and flip state after a single check
+// (preserving the load balancer's original zero-config behavior).
+func DefaultHealthCheckConfig() HealthCheckConfig {
+	return HealthCheckConfig{
+		Path:               "/health",
+		Interval:           10 * time.Second,
+		Timeout:            2 * time.Second,
+		ExpectedStatus:     0, // 0 means "any status below 500"
+		HealthyThreshold:   1,
+		UnhealthyThreshold: 1,
+	}
+}
+
+// LoadHealthCheckConfigs reads a JSON file mapping backend URL strings to
+// HealthCheckConfig overrides, e.g.:
+//
+//	{
+//	  "http://localhost:8081": {"path": "/status", "expectedStatus": 200, "unhealthyThreshold": 3}
+//	}
+//
+// Backends not present in the file keep DefaultHealthCheckConfig.
+func LoadHealthCheckConfigs(path string) (map[string]HealthCheckConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading health check config: %w", err)
+	}
+
+	raw := map[string]HealthCheckConfig{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("parsing health check config: %w", err)
+	}
+
+	configs := make(map[string]HealthCheckConfig, len(raw))
+	for url, cfg := range raw {
+		merged := DefaultHealthCheckConfig()
+		if cfg.Path != "" {
+			merged.Path = cfg.Path
+		}
+		if cfg.IntervalSeconds > 0 {
+			merged.Interval = time.Duration(cfg.IntervalSeconds) * time.Second
+		}
+		if cfg.TimeoutSeconds > 0 {
+			merged.Timeout = time.Duration(cfg.TimeoutSeconds) * time.Second
+		}
+		if cfg.ExpectedStatus != 0 {
+			merged.ExpectedStatus = cfg.ExpectedStatus
+		}
+		if cfg.HealthyThreshold > 0 {
+			merged.HealthyThreshold = cfg.HealthyThreshold
+		}
+		if cfg.UnhealthyThreshold > 0 {
+			merged.UnhealthyThreshold = cfg.UnhealthyThreshold
+		}
+		if cfg.BodyRegex != "" {
+			re, err := regexp.Compile(cfg.BodyRegex)
+			if err != nil {
+				return nil, fmt.Errorf("invalid bodyRegex for %s: %w", url, err)
+			}
+			merged.BodyRegex = cfg.BodyRegex
+			merged.bodyRE = re
+		}
+		configs[url] = merged
+	}
+	return configs, nil
+}
+
+// checkBackendHealth probes a single backend according to its HealthCheckConfig
+// and reports whether the probe succeeded. FastCGI backends have no HTTP
+// endpoint to probe, so a successful TCP dial is treated as healthy.
+func checkBackendHealth(b *Backend) bool {
+	cfg := b.HealthConfig
+
+	if b.URL.Scheme == "fastcgi" {
+		conn, err := net.DialTimeout("tcp", b.URL.Host, cfg.Timeout)
+		if err != nil {
+			return false
+		}
+		conn.Close()
+		return true
+	}
+
+	client := http.Client{Timeout: cfg.Timeout}
+
+	healthURL := *b.URL
+	healthURL.Path = cfg.Path
+
+	resp, err := client.Get(healthURL.String())
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+
+	if cfg.ExpectedStatus != 0 {
+		if resp.StatusCode != cfg.ExpectedStatus {
+			return false
+		}
+	} else if resp.StatusCode >= 500 {
+		return false
+	}
+
+	if cfg.bodyRE != nil {
+		// Read fully (up to the cap) rather than a single Read call, since
+		// io.Reader is allowed to return fewer bytes than requested and a
+		// short read could split the regex match across a read boundary.
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		if !cfg.bodyRE.Match(body) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// RecordHealthResult applies a single probe outcome to the backend's
+// consecutive success/failure counters and flips Alive only once the
+// configured threshold of matching consecutive results is reached, so a
+// single flaky probe doesn't flap the backend in or out of rotation.
+func (b *Backend) RecordHealthResult(success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if success {
+		b.consecSuccess++
+		b.consecFail = 0
+		if !b.Alive && b.consecSuccess >= b.HealthConfig.HealthyThreshold {
+			b.Alive = true
+		}
+	} else {
+		b.consecFail++
+		b.consecSuccess = 0
+		if b.Alive && b.consecFail >= b.HealthConfig.UnhealthyThreshold {
+			b.Alive = false
+		}
+	}
+}
+
+// backendHealthCheckRoutine periodically probes a single backend at its own
+// configured interval, independent of every other backend's schedule.
+func backendHealthCheckRoutine(ctx context.Context, b *Backend, metrics *MetricsRegistry) {
+	ticker := time.NewTicker(b.HealthConfig.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			success := checkBackendHealth(b)
+			if !success {
+				metrics.RecordHealthCheckFailure(b.URL.String())
+			}
+			b.RecordHealthResult(success)
+			status := "up"
+			if !b.IsAlive() {
+				status = "down"
+			}
+			logHealthCheck(HealthCheckLogEntry{Backend: b.URL.String(), Status: status})
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+)
+
+// RequestLogEntry is the structured JSON record emitted for every proxied
+// request, suitable for shipping to a central log store.
+type RequestLogEntry struct {
+	Backend       string `json:"backend"`
+	Method        string `json:"method"`
+	Path          string `json:"path"`
+	Status        int    `json:"status,omitempty"`
+	DurationMs    int64  `json:"duration_ms"`
+	ClientIP      string `json:"client_ip"`
+	UpstreamError string `json:"upstream_error,omitempty"`
+}
+
+// logRequest emits a RequestLogEntry as a single line of JSON.
+func logRequest(entry RequestLogEntry) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		log.Printf("Error marshaling request log entry: %v", err)
+		return
+	}
+	fmt.Println(string(data))
+}
+
+// HealthCheckLogEntry is the structured JSON record emitted for every active
+// health check probe result.
+type HealthCheckLogEntry struct {
+	Backend string `json:"backend"`
+	Status  string `json:"status"`
+}
+
+// logHealthCheck emits a HealthCheckLogEntry as a single line of JSON.
+func logHealthCheck(entry HealthCheckLogEntry) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		log.Printf("Error marshaling health check log entry: %v", err)
+		return
+	}
+	fmt.Println(string(data))
+}
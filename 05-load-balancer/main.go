@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
@@ -10,6 +11,7 @@ import (
 	"net/url"
 	"os"
 	"os/signal"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -21,8 +23,37 @@ import (
 type Backend struct {
 	URL          *url.URL
 	Alive        bool
+	Weight       int
 	mu           sync.RWMutex
 	ReverseProxy *http.Client
+	Transport    Transport
+
+	// stop cancels the context passed to this backend's background
+	// health-check and circuit-window-reset goroutines, set by
+	// startBackendLifecycle and invoked by RemoveBackend so those goroutines
+	// don't leak once the backend is removed from the pool.
+	stop context.CancelFunc
+
+	conns         int64 // active connections, for least-conn
+	currentWeight int64 // running weight, for weighted round-robin
+
+	HealthConfig  HealthCheckConfig
+	consecSuccess int
+	consecFail    int
+
+	CBConfig CircuitBreakerConfig
+
+	circuitState          int32
+	halfOpenProbeInFlight int32
+	windowRequests        int64
+	windowFailures        int64
+	requestsTotal         int64
+	failuresTotal         int64
+	ejectionsTotal        int64
+	lastEjectedAtUnixNano int64
+	latencyEWMABits       uint64
+
+	draining int32
 }
 
 // SetAlive sets the backend's alive status
@@ -39,113 +70,249 @@ func (b *Backend) IsAlive() bool {
 	return b.Alive
 }
 
-// ServerPool holds information about backend servers
+// ActiveConns returns the number of requests currently being proxied to this backend.
+func (b *Backend) ActiveConns() int64 {
+	return atomic.LoadInt64(&b.conns)
+}
+
+// IncConns increments the active connection counter, returning the new value.
+func (b *Backend) IncConns() int64 {
+	return atomic.AddInt64(&b.conns, 1)
+}
+
+// DecConns decrements the active connection counter.
+func (b *Backend) DecConns() {
+	atomic.AddInt64(&b.conns, -1)
+}
+
+// CurrentWeight returns the backend's running weight used by weighted round-robin.
+func (b *Backend) CurrentWeight() int64 {
+	return atomic.LoadInt64(&b.currentWeight)
+}
+
+// AddCurrentWeight adds delta to the backend's running weight, returning the new value.
+func (b *Backend) AddCurrentWeight(delta int64) int64 {
+	return atomic.AddInt64(&b.currentWeight, delta)
+}
+
+// Drain marks the backend as draining: Available reports it as unavailable so
+// GetNextPeer stops routing new requests to it, while requests already in
+// flight (tracked via ActiveConns) are left to finish on their own.
+func (b *Backend) Drain() {
+	atomic.StoreInt32(&b.draining, 1)
+}
+
+// Undrain restores a draining backend to normal rotation.
+func (b *Backend) Undrain() {
+	atomic.StoreInt32(&b.draining, 0)
+}
+
+// IsDraining reports whether the backend is draining.
+func (b *Backend) IsDraining() bool {
+	return atomic.LoadInt32(&b.draining) == 1
+}
+
+// Stop cancels the backend's background health-check and circuit-window-reset
+// goroutines, if any were started for it via startBackendLifecycle.
+func (b *Backend) Stop() {
+	if b.stop != nil {
+		b.stop()
+	}
+}
+
+// startBackendLifecycle starts b's per-backend background goroutines
+// (active health checks and circuit-window resets) on a context derived from
+// parent, and records its cancel func on b so RemoveBackend can stop them
+// when the backend leaves the pool instead of leaking them for the life of
+// the process.
+func startBackendLifecycle(parent context.Context, b *Backend, metrics *MetricsRegistry) {
+	backendCtx, cancel := context.WithCancel(parent)
+	b.stop = cancel
+	go backendHealthCheckRoutine(backendCtx, b, metrics)
+	go circuitWindowResetRoutine(backendCtx, b)
+}
+
+// ServerPool holds information about backend servers. backends is guarded by
+// mu so it can be safely mutated by the admin API and config reload while
+// concurrent requests are being routed.
 type ServerPool struct {
+	mu       sync.RWMutex
 	backends []*Backend
 	current  uint64
+	policy   Policy
+	Metrics  *MetricsRegistry
 }
 
 // AddBackend adds a backend to the pool
 func (s *ServerPool) AddBackend(backend *Backend) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	s.backends = append(s.backends, backend)
 }
 
-// NextIndex increments and returns the next index in round-robin fashion
-func (s *ServerPool) NextIndex() int {
-	return int(atomic.AddUint64(&s.current, uint64(1)) % uint64(len(s.backends)))
-}
-
-// GetNextPeer returns the next available backend using round-robin
-func (s *ServerPool) GetNextPeer() *Backend {
-	// Loop through all backends to find an alive one
-	next := s.NextIndex()
-	l := len(s.backends) + next
-
-	for i := next; i < l; i++ {
-		idx := i % len(s.backends)
-		if s.backends[idx].IsAlive() {
-			if i != next {
-				atomic.StoreUint64(&s.current, uint64(idx))
-			}
-			return s.backends[idx]
+// RemoveBackend removes the backend with the given URL from the pool,
+// reporting whether a matching backend was found.
+func (s *ServerPool) RemoveBackend(urlStr string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, b := range s.backends {
+		if b.URL.String() == urlStr {
+			b.Stop()
+			s.backends = append(s.backends[:i], s.backends[i+1:]...)
+			return true
 		}
 	}
-	return nil
+	return false
 }
 
-// HealthCheck performs health check on all backends
-func (s *ServerPool) HealthCheck() {
+// FindBackend returns the backend with the given URL, or nil if none matches.
+func (s *ServerPool) FindBackend(urlStr string) *Backend {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	for _, b := range s.backends {
-		status := "up"
-		alive := isBackendAlive(b.URL)
-		b.SetAlive(alive)
-		if !alive {
-			status = "down"
+		if b.URL.String() == urlStr {
+			return b
 		}
-		log.Printf("Health check: %s [%s]", b.URL, status)
 	}
+	return nil
 }
 
-// GetBackendStatus returns status of all backends
-func (s *ServerPool) GetBackendStatus() []map[string]interface{} {
-	status := make([]map[string]interface{}, len(s.backends))
-	for i, b := range s.backends {
-		status[i] = map[string]interface{}{
-			"url":   b.URL.String(),
-			"alive": b.IsAlive(),
-		}
-	}
-	return status
+// Backends returns a snapshot of the current backend list, safe to range
+// over without holding s.mu.
+func (s *ServerPool) Backends() []*Backend {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]*Backend, len(s.backends))
+	copy(out, s.backends)
+	return out
 }
 
-// isBackendAlive checks if a backend is alive
-func isBackendAlive(u *url.URL) bool {
-	timeout := 2 * time.Second
-	conn := http.Client{
-		Timeout: timeout,
+// SetPolicy sets the selection policy used by GetNextPeer.
+func (s *ServerPool) SetPolicy(policy Policy) {
+	s.policy = policy
+}
+
+// NextIndex increments and returns the next index in round-robin fashion
+func (s *ServerPool) NextIndex() int {
+	s.mu.RLock()
+	n := len(s.backends)
+	s.mu.RUnlock()
+	if n == 0 {
+		return 0
 	}
+	return int(atomic.AddUint64(&s.current, uint64(1)) % uint64(n))
+}
 
-	// Try to reach the backend's health endpoint or root
-	healthURL := *u
-	healthURL.Path = "/health"
+// SetCurrent sets the round-robin cursor to idx.
+func (s *ServerPool) SetCurrent(idx uint64) {
+	atomic.StoreUint64(&s.current, idx)
+}
 
-	resp, err := conn.Get(healthURL.String())
-	if err != nil {
-		// If /health fails, try the root path
-		resp, err = conn.Get(u.String())
-		if err != nil {
-			return false
+// GetNextPeer returns the next available backend according to the pool's selection policy
+func (s *ServerPool) GetNextPeer(r *http.Request) *Backend {
+	backends := s.Backends()
+	if len(backends) == 0 {
+		return nil
+	}
+	return s.policy.Select(backends, r)
+}
+
+// HealthCheck performs an immediate, synchronous health check on all backends.
+func (s *ServerPool) HealthCheck() {
+	for _, b := range s.Backends() {
+		success := checkBackendHealth(b)
+		if !success {
+			s.Metrics.RecordHealthCheckFailure(b.URL.String())
+		}
+		b.RecordHealthResult(success)
+		status := "up"
+		if !b.IsAlive() {
+			status = "down"
 		}
+		logHealthCheck(HealthCheckLogEntry{Backend: b.URL.String(), Status: status})
 	}
-	defer resp.Body.Close()
+}
 
-	// Consider the backend alive if we get any response
-	return resp.StatusCode < 500
+// BackendStatus is the JSON shape of one backend's entry in /lb-status.
+type BackendStatus struct {
+	URL            string  `json:"url"`
+	Alive          bool    `json:"alive"`
+	Draining       bool    `json:"draining"`
+	Circuit        string  `json:"circuit"`
+	RequestsTotal  int64   `json:"requestsTotal"`
+	FailuresTotal  int64   `json:"failuresTotal"`
+	EjectionsTotal int64   `json:"ejectionsTotal"`
+	LatencyEWMAMs  float64 `json:"latencyEwmaMs"`
+	LastEjectedAt  string  `json:"lastEjectedAt,omitempty"`
 }
 
-// healthCheckRoutine performs periodic health checks
-func healthCheckRoutine(ctx context.Context, s *ServerPool, interval time.Duration) {
-	ticker := time.NewTicker(interval)
-	defer ticker.Stop()
+// backendStatus builds one backend's /lb-status and admin API entry.
+func backendStatus(b *Backend) BackendStatus {
+	metrics := b.Metrics()
+	entry := BackendStatus{
+		URL:            b.URL.String(),
+		Alive:          b.IsAlive(),
+		Draining:       b.IsDraining(),
+		Circuit:        metrics.State,
+		RequestsTotal:  metrics.RequestsTotal,
+		FailuresTotal:  metrics.FailuresTotal,
+		EjectionsTotal: metrics.EjectionsTotal,
+		LatencyEWMAMs:  metrics.LatencyEWMAMs,
+	}
+	if !metrics.LastEjectedAt.IsZero() {
+		entry.LastEjectedAt = metrics.LastEjectedAt.Format(time.RFC3339)
+	}
+	return entry
+}
 
-	for {
-		select {
-		case <-ctx.Done():
-			log.Println("Stopping health check routine")
-			return
-		case <-ticker.C:
-			log.Println("Starting health check...")
-			s.HealthCheck()
-		}
+// GetBackendStatus returns status of all backends, including circuit breaker metrics.
+func (s *ServerPool) GetBackendStatus() []BackendStatus {
+	backends := s.Backends()
+	status := make([]BackendStatus, len(backends))
+	for i, b := range backends {
+		status[i] = backendStatus(b)
 	}
+	return status
 }
 
 // lb performs load balancing
 func lb(w http.ResponseWriter, r *http.Request, s *ServerPool) {
-	peer := s.GetNextPeer()
+	peer := s.GetNextPeer(r)
 	if peer == nil {
 		http.Error(w, "Service unavailable", http.StatusServiceUnavailable)
-		log.Printf("No available backends for request: %s %s", r.Method, r.URL.Path)
+		logRequest(RequestLogEntry{
+			Method:        r.Method,
+			Path:          r.URL.Path,
+			Status:        http.StatusServiceUnavailable,
+			ClientIP:      remoteIP(r),
+			UpstreamError: "no available backends",
+		})
+		return
+	}
+
+	// WebSocket upgrades are a persistent, bidirectional stream that can't be
+	// represented as a single http.Response, so they bypass the Transport
+	// abstraction and splice raw connections together instead. They still
+	// go through TryDispatch/RecordOutcome (treating a successful upgrade as
+	// success and a dial/write/hijack failure as failure) so the circuit
+	// breaker's HalfOpen single-probe invariant and passive failure tracking
+	// also cover WebSocket-only traffic.
+	if isWebSocketUpgrade(r) {
+		if !peer.TryDispatch() {
+			http.Error(w, "Service unavailable", http.StatusServiceUnavailable)
+			logRequest(RequestLogEntry{
+				Backend:       peer.URL.String(),
+				Method:        r.Method,
+				Path:          r.URL.Path,
+				Status:        http.StatusServiceUnavailable,
+				ClientIP:      remoteIP(r),
+				UpstreamError: "backend not available for dispatch",
+			})
+			return
+		}
+		peer.IncConns()
+		proxyWebSocketRequest(w, r, peer)
+		peer.DecConns()
 		return
 	}
 
@@ -158,35 +325,74 @@ func lb(w http.ResponseWriter, r *http.Request, s *ServerPool) {
 	proxyReq, err := http.NewRequest(r.Method, targetURL.String(), r.Body)
 	if err != nil {
 		http.Error(w, "Error creating request", http.StatusInternalServerError)
-		log.Printf("Error creating proxy request: %v", err)
+		logRequest(RequestLogEntry{
+			Backend:       peer.URL.String(),
+			Method:        r.Method,
+			Path:          r.URL.Path,
+			Status:        http.StatusInternalServerError,
+			ClientIP:      remoteIP(r),
+			UpstreamError: fmt.Sprintf("creating proxy request: %v", err),
+		})
 		return
 	}
 
-	// Copy headers
+	// Copy headers, then strip hop-by-hop headers per RFC 7230
 	for key, values := range r.Header {
 		for _, value := range values {
 			proxyReq.Header.Add(key, value)
 		}
 	}
+	stripHopByHopHeaders(proxyReq.Header)
 
-	// Add X-Forwarded-For header
-	if clientIP := r.RemoteAddr; clientIP != "" {
-		proxyReq.Header.Set("X-Forwarded-For", clientIP)
-	}
+	// Append to any existing X-Forwarded-For chain rather than overwriting it
+	appendForwardedFor(proxyReq.Header, remoteIP(r))
 	proxyReq.Header.Set("X-Forwarded-Host", r.Host)
 	proxyReq.Header.Set("X-Forwarded-Proto", "http")
 
-	// Send the request
-	resp, err := peer.ReverseProxy.Do(proxyReq)
+	// GetNextPeer's Available() filtering doesn't consume a HalfOpen backend's
+	// single recovery probe; only TryDispatch on the backend actually chosen
+	// to handle this request does, so the probe isn't burned by a backend that
+	// merely passed candidate filtering without being dispatched to.
+	if !peer.TryDispatch() {
+		http.Error(w, "Service unavailable", http.StatusServiceUnavailable)
+		logRequest(RequestLogEntry{
+			Backend:       peer.URL.String(),
+			Method:        r.Method,
+			Path:          r.URL.Path,
+			Status:        http.StatusServiceUnavailable,
+			ClientIP:      remoteIP(r),
+			UpstreamError: "backend not available for dispatch",
+		})
+		return
+	}
+
+	// Send the request, tracking active connections for the least-conn policy
+	// and the outcome for the circuit breaker
+	peer.IncConns()
+	start := time.Now()
+	resp, err := peer.Transport.RoundTrip(proxyReq)
+	duration := time.Since(start)
+	peer.DecConns()
 	if err != nil {
-		log.Printf("Error forwarding request to %s: %v", peer.URL, err)
-		peer.SetAlive(false)
+		peer.RecordOutcome(false, duration)
+		s.Metrics.ObserveRequest(peer.URL.String(), r.Method, http.StatusBadGateway, duration)
+		logRequest(RequestLogEntry{
+			Backend:       peer.URL.String(),
+			Method:        r.Method,
+			Path:          r.URL.Path,
+			DurationMs:    duration.Milliseconds(),
+			ClientIP:      remoteIP(r),
+			UpstreamError: err.Error(),
+		})
 		http.Error(w, "Bad Gateway", http.StatusBadGateway)
 		return
 	}
 	defer resp.Body.Close()
+	peer.RecordOutcome(resp.StatusCode < 500, duration)
+	s.Metrics.ObserveRequest(peer.URL.String(), r.Method, resp.StatusCode, duration)
 
-	// Copy response headers
+	// Copy response headers, stripping hop-by-hop headers per RFC 7230
+	stripHopByHopHeaders(resp.Header)
 	for key, values := range resp.Header {
 		for _, value := range values {
 			w.Header().Add(key, value)
@@ -196,70 +402,146 @@ func lb(w http.ResponseWriter, r *http.Request, s *ServerPool) {
 	// Set status code
 	w.WriteHeader(resp.StatusCode)
 
-	// Copy response body
-	_, err = io.Copy(w, resp.Body)
+	// Stream the response body, flushing as it arrives for SSE; buffer
+	// normally otherwise
+	if isEventStream(resp) {
+		err = streamResponse(w, resp)
+	} else {
+		_, err = io.Copy(w, resp.Body)
+	}
+	var copyErr string
 	if err != nil {
-		log.Printf("Error copying response body: %v", err)
+		copyErr = fmt.Sprintf("copying response body: %v", err)
 	}
 
-	log.Printf("Forwarded %s %s to %s [%d]", r.Method, r.URL.Path, peer.URL, resp.StatusCode)
+	logRequest(RequestLogEntry{
+		Backend:       peer.URL.String(),
+		Method:        r.Method,
+		Path:          r.URL.Path,
+		Status:        resp.StatusCode,
+		DurationMs:    duration.Milliseconds(),
+		ClientIP:      remoteIP(r),
+		UpstreamError: copyErr,
+	})
 }
 
 // statusHandler returns the status of all backends
 func statusHandler(w http.ResponseWriter, r *http.Request, s *ServerPool) {
 	w.Header().Set("Content-Type", "application/json")
-	status := s.GetBackendStatus()
+	body, err := json.MarshalIndent(map[string]interface{}{"backends": s.GetBackendStatus()}, "", "  ")
+	if err != nil {
+		http.Error(w, "Error encoding status", http.StatusInternalServerError)
+		log.Printf("Error encoding backend status: %v", err)
+		return
+	}
+	w.Write(body)
+	w.Write([]byte("\n"))
+}
 
-	fmt.Fprintf(w, "{\n  \"backends\": [\n")
-	for i, backend := range status {
-		alive := "true"
-		if !backend["alive"].(bool) {
-			alive = "false"
-		}
-		fmt.Fprintf(w, "    {\"url\": \"%s\", \"alive\": %s}", backend["url"], alive)
-		if i < len(status)-1 {
-			fmt.Fprintf(w, ",")
-		}
-		fmt.Fprintf(w, "\n")
+// metricsHandler exposes the load balancer's metrics in Prometheus text format.
+func metricsHandler(w http.ResponseWriter, r *http.Request, s *ServerPool) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	if err := s.Metrics.WriteTo(w, s); err != nil {
+		http.Error(w, "Error encoding metrics", http.StatusInternalServerError)
+		log.Printf("Error encoding metrics: %v", err)
 	}
-	fmt.Fprintf(w, "  ]\n}\n")
 }
 
 func main() {
 	var serverList string
 	var port int
 	var healthCheckInterval int
-
-	flag.StringVar(&serverList, "backends", "", "Comma-separated list of backend servers (e.g., http://localhost:8081,http://localhost:8082)")
+	var policyName string
+	var healthConfigPath string
+	var fastcgiRoot string
+	var fastcgiSplitPath string
+	var adminToken string
+	var configPath string
+
+	flag.StringVar(&serverList, "backends", "", "Comma-separated list of backend servers (e.g., http://localhost:8081,http://localhost:8082 or fastcgi://localhost:9000). Append :<weight> to set a backend's weight, e.g. http://localhost:8081:5. Ignored if -config is set.")
 	flag.IntVar(&port, "port", 8080, "Port to run the load balancer on")
-	flag.IntVar(&healthCheckInterval, "health-check-interval", 10, "Health check interval in seconds")
+	flag.IntVar(&healthCheckInterval, "health-check-interval", 10, "Default health check interval in seconds, used for backends with no override in -health-config")
+	flag.StringVar(&policyName, "policy", "round-robin", "Load balancing policy: round-robin, random, least-conn, weighted-round-robin, ip-hash")
+	flag.StringVar(&healthConfigPath, "health-config", "", "Path to a JSON file of per-backend health check overrides (path, thresholds, expected status, body regex)")
+	flag.StringVar(&fastcgiRoot, "fastcgi-root", "", "DOCUMENT_ROOT / SCRIPT_FILENAME prefix for fastcgi:// backends")
+	flag.StringVar(&fastcgiSplitPath, "fastcgi-split-path", ".php", "Comma-separated path markers (e.g. .php) that split SCRIPT_NAME from PATH_INFO for fastcgi:// backends")
+	flag.StringVar(&adminToken, "admin-token", "", "Bearer token required to call the /admin/backends API. Leave empty to disable the admin API.")
+	flag.StringVar(&configPath, "config", "", "Path to a JSON file of backend specs ([{\"url\":...,\"weight\":...}, ...]). If set, this replaces -backends and is reloaded on SIGHUP.")
 	flag.Parse()
 
-	if serverList == "" {
-		log.Fatal("Please provide at least one backend server using -backends flag")
+	if serverList == "" && configPath == "" {
+		log.Fatal("Please provide at least one backend server using -backends or -config")
+	}
+
+	// Load per-backend health check overrides, if provided
+	healthConfigs := map[string]HealthCheckConfig{}
+	if healthConfigPath != "" {
+		loaded, err := LoadHealthCheckConfigs(healthConfigPath)
+		if err != nil {
+			log.Fatalf("Failed to load -health-config: %v", err)
+		}
+		healthConfigs = loaded
+	}
+
+	factory := &BackendFactory{
+		HealthConfigs:              healthConfigs,
+		DefaultHealthCheckInterval: time.Duration(healthCheckInterval) * time.Second,
+		FastCGIRoot:                fastcgiRoot,
+		FastCGISplitPath:           strings.Split(fastcgiSplitPath, ","),
 	}
 
 	// Parse backend servers
-	serverPool := &ServerPool{}
-	servers := strings.Split(serverList, ",")
+	serverPool := &ServerPool{Metrics: NewMetricsRegistry()}
 
-	for _, server := range servers {
-		server = strings.TrimSpace(server)
-		serverURL, err := url.Parse(server)
+	var specs []BackendSpec
+	if configPath != "" {
+		loaded, err := loadBackendSpecs(configPath)
 		if err != nil {
-			log.Fatalf("Invalid server URL '%s': %v", server, err)
+			log.Fatalf("Failed to load -config: %v", err)
 		}
+		specs = loaded
+	} else {
+		for _, server := range strings.Split(serverList, ",") {
+			server = strings.TrimSpace(server)
+			weight := 1
+			// A trailing ":<weight>" is an optional colon-separated segment after
+			// the scheme, host and port, e.g. http://localhost:8081:5. Since
+			// host:port already contains one colon, only treat a trailing segment
+			// as a weight when there are at least two colons after the scheme —
+			// otherwise "http://localhost:8081" would have its port colon mistaken
+			// for the weight separator and its port silently dropped.
+			scheme, rest := server, ""
+			if idx := strings.Index(server, "://"); idx != -1 {
+				scheme, rest = server[:idx+3], server[idx+3:]
+			}
+			if strings.Count(rest, ":") >= 2 {
+				idx := strings.LastIndex(rest, ":")
+				if w, err := strconv.Atoi(rest[idx+1:]); err == nil {
+					weight = w
+					server = scheme + rest[:idx]
+				}
+			}
+			specs = append(specs, BackendSpec{URL: server, Weight: weight})
+		}
+	}
 
-		backend := &Backend{
-			URL:   serverURL,
-			Alive: true,
-			ReverseProxy: &http.Client{
-				Timeout: 30 * time.Second,
-			},
+	for _, spec := range specs {
+		serverURL, err := url.Parse(spec.URL)
+		if err != nil {
+			log.Fatalf("Invalid server URL '%s': %v", spec.URL, err)
 		}
+
+		backend := factory.Build(serverURL, spec)
 		serverPool.AddBackend(backend)
-		log.Printf("Configured backend server: %s", serverURL)
+		log.Printf("Configured backend server: %s (weight=%d, health-check-path=%s)", serverURL, backend.Weight, backend.HealthConfig.Path)
+	}
+
+	policy, err := NewPolicy(policyName, serverPool)
+	if err != nil {
+		log.Fatalf("Invalid -policy: %v", err)
 	}
+	serverPool.SetPolicy(policy)
+	log.Printf("Using load balancing policy: %s", policyName)
 
 	// Create a context for graceful shutdown
 	ctx, cancel := context.WithCancel(context.Background())
@@ -269,8 +551,10 @@ func main() {
 	log.Println("Performing initial health check...")
 	serverPool.HealthCheck()
 
-	// Start health check routine
-	go healthCheckRoutine(ctx, serverPool, time.Duration(healthCheckInterval)*time.Second)
+	// Start one health check routine per backend, each on its own configured interval
+	for _, b := range serverPool.Backends() {
+		startBackendLifecycle(ctx, b, serverPool.Metrics)
+	}
 
 	// Create HTTP server
 	mux := http.NewServeMux()
@@ -280,13 +564,30 @@ func main() {
 		statusHandler(w, r, serverPool)
 	})
 
+	// Prometheus metrics endpoint
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		metricsHandler(w, r, serverPool)
+	})
+
+	// Admin API: add/list backends, remove or drain a backend by URL
+	mux.HandleFunc("/admin/backends", func(w http.ResponseWriter, r *http.Request) {
+		adminBackendsHandler(w, r, serverPool, factory, ctx, adminToken)
+	})
+	mux.HandleFunc("/admin/backends/", func(w http.ResponseWriter, r *http.Request) {
+		adminBackendHandler(w, r, serverPool, adminToken)
+	})
+
 	// Main load balancer handler
 	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		// Don't load balance requests to the status endpoint
+		// Don't load balance requests to the status or metrics endpoints
 		if r.URL.Path == "/lb-status" {
 			statusHandler(w, r, serverPool)
 			return
 		}
+		if r.URL.Path == "/metrics" {
+			metricsHandler(w, r, serverPool)
+			return
+		}
 		lb(w, r, serverPool)
 	})
 
@@ -312,10 +613,28 @@ func main() {
 		}
 	}()
 
+	// Reload the backend pool from -config on SIGHUP, diffing desired vs
+	// current backends and applying add/remove/weight changes in place.
+	if configPath != "" {
+		go func() {
+			sighup := make(chan os.Signal, 1)
+			signal.Notify(sighup, syscall.SIGHUP)
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-sighup:
+					log.Println("Received SIGHUP, reloading backend config...")
+					reloadBackends(ctx, serverPool, factory, configPath)
+				}
+			}
+		}()
+	}
+
 	// Start server
 	log.Printf("Load balancer starting on port %d", port)
 	log.Printf("Health check interval: %d seconds", healthCheckInterval)
-	log.Printf("Backend servers: %d", len(serverPool.backends))
+	log.Printf("Backend servers: %d", len(serverPool.Backends()))
 
 	if err := server.ListenAndServe(); err != http.ErrServerClosed {
 		log.Fatalf("HTTP server error: %v", err)
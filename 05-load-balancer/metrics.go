@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// defaultDurationBuckets are the histogram bucket boundaries, in seconds,
+// used for lb_request_duration_seconds.
+var defaultDurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Histogram is a minimal Prometheus-style cumulative histogram: each bucket
+// counts observations less than or equal to its upper bound.
+type Histogram struct {
+	buckets []float64
+
+	mu     sync.Mutex
+	counts []int64
+	sum    float64
+	count  int64
+}
+
+// NewHistogram creates a Histogram with the given bucket boundaries.
+func NewHistogram(buckets []float64) *Histogram {
+	return &Histogram{buckets: buckets, counts: make([]int64, len(buckets))}
+}
+
+// Observe records one sample.
+func (h *Histogram) Observe(value float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, bound := range h.buckets {
+		if value <= bound {
+			h.counts[i]++
+		}
+	}
+	h.sum += value
+	h.count++
+}
+
+// requestCounterKey identifies one lb_requests_total series.
+type requestCounterKey struct {
+	Backend string
+	Method  string
+	Status  string
+}
+
+// MetricsRegistry accumulates the Prometheus counters and histograms this
+// load balancer exposes at /metrics. Per-backend gauges (up, active
+// connections) are read live from ServerPool rather than stored here.
+type MetricsRegistry struct {
+	mu                       sync.Mutex
+	requestsTotal            map[requestCounterKey]int64
+	requestDuration          map[string]*Histogram
+	healthCheckFailuresTotal map[string]int64
+}
+
+// NewMetricsRegistry creates an empty MetricsRegistry.
+func NewMetricsRegistry() *MetricsRegistry {
+	return &MetricsRegistry{
+		requestsTotal:            map[requestCounterKey]int64{},
+		requestDuration:          map[string]*Histogram{},
+		healthCheckFailuresTotal: map[string]int64{},
+	}
+}
+
+// ObserveRequest records the outcome of one proxied request.
+func (m *MetricsRegistry) ObserveRequest(backend, method string, status int, duration time.Duration) {
+	m.mu.Lock()
+	key := requestCounterKey{Backend: backend, Method: method, Status: strconv.Itoa(status)}
+	m.requestsTotal[key]++
+	hist, ok := m.requestDuration[backend]
+	if !ok {
+		hist = NewHistogram(defaultDurationBuckets)
+		m.requestDuration[backend] = hist
+	}
+	m.mu.Unlock()
+
+	hist.Observe(duration.Seconds())
+}
+
+// RecordHealthCheckFailure increments the failure counter for backend.
+func (m *MetricsRegistry) RecordHealthCheckFailure(backend string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.healthCheckFailuresTotal[backend]++
+}
+
+// WriteTo renders all metrics, plus per-backend gauges read live from pool,
+// in Prometheus text exposition format.
+func (m *MetricsRegistry) WriteTo(w io.Writer, pool *ServerPool) error {
+	m.mu.Lock()
+	requestKeys := make([]requestCounterKey, 0, len(m.requestsTotal))
+	for k := range m.requestsTotal {
+		requestKeys = append(requestKeys, k)
+	}
+	sort.Slice(requestKeys, func(i, j int) bool {
+		if requestKeys[i].Backend != requestKeys[j].Backend {
+			return requestKeys[i].Backend < requestKeys[j].Backend
+		}
+		if requestKeys[i].Method != requestKeys[j].Method {
+			return requestKeys[i].Method < requestKeys[j].Method
+		}
+		return requestKeys[i].Status < requestKeys[j].Status
+	})
+	requestCounts := make(map[requestCounterKey]int64, len(m.requestsTotal))
+	for k, v := range m.requestsTotal {
+		requestCounts[k] = v
+	}
+	histograms := make(map[string]*Histogram, len(m.requestDuration))
+	for k, v := range m.requestDuration {
+		histograms[k] = v
+	}
+	healthFailures := make(map[string]int64, len(m.healthCheckFailuresTotal))
+	for k, v := range m.healthCheckFailuresTotal {
+		healthFailures[k] = v
+	}
+	m.mu.Unlock()
+
+	fmt.Fprintln(w, "# HELP lb_requests_total Total number of requests proxied to a backend, by method and status.")
+	fmt.Fprintln(w, "# TYPE lb_requests_total counter")
+	for _, k := range requestKeys {
+		fmt.Fprintf(w, "lb_requests_total{backend=%q,method=%q,status=%q} %d\n", k.Backend, k.Method, k.Status, requestCounts[k])
+	}
+
+	fmt.Fprintln(w, "# HELP lb_request_duration_seconds Histogram of proxied request durations, by backend.")
+	fmt.Fprintln(w, "# TYPE lb_request_duration_seconds histogram")
+	for _, backend := range sortedHistogramKeys(histograms) {
+		writeHistogram(w, backend, histograms[backend])
+	}
+
+	backends := pool.Backends()
+	fmt.Fprintln(w, "# HELP lb_backend_up Whether the load balancer currently considers a backend healthy and available.")
+	fmt.Fprintln(w, "# TYPE lb_backend_up gauge")
+	for _, b := range backends {
+		up := 0
+		if b.Available() {
+			up = 1
+		}
+		fmt.Fprintf(w, "lb_backend_up{backend=%q} %d\n", b.URL.String(), up)
+	}
+	fmt.Fprintln(w, "# HELP lb_backend_active_connections Number of requests currently being proxied to a backend.")
+	fmt.Fprintln(w, "# TYPE lb_backend_active_connections gauge")
+	for _, b := range backends {
+		fmt.Fprintf(w, "lb_backend_active_connections{backend=%q} %d\n", b.URL.String(), b.ActiveConns())
+	}
+
+	fmt.Fprintln(w, "# HELP lb_health_check_failures_total Total number of failed active health check probes, by backend.")
+	fmt.Fprintln(w, "# TYPE lb_health_check_failures_total counter")
+	for _, backend := range sortedCounterKeys(healthFailures) {
+		fmt.Fprintf(w, "lb_health_check_failures_total{backend=%q} %d\n", backend, healthFailures[backend])
+	}
+
+	return nil
+}
+
+// writeHistogram renders one backend's histogram in Prometheus format:
+// cumulative bucket counts, then _sum and _count.
+func writeHistogram(w io.Writer, backend string, hist *Histogram) {
+	hist.mu.Lock()
+	defer hist.mu.Unlock()
+
+	for i, bound := range hist.buckets {
+		fmt.Fprintf(w, "lb_request_duration_seconds_bucket{backend=%q,le=%q} %d\n", backend, strconv.FormatFloat(bound, 'g', -1, 64), hist.counts[i])
+	}
+	fmt.Fprintf(w, "lb_request_duration_seconds_bucket{backend=%q,le=\"+Inf\"} %d\n", backend, hist.count)
+	fmt.Fprintf(w, "lb_request_duration_seconds_sum{backend=%q} %s\n", backend, strconv.FormatFloat(hist.sum, 'g', -1, 64))
+	fmt.Fprintf(w, "lb_request_duration_seconds_count{backend=%q} %d\n", backend, hist.count)
+}
+
+// sortedHistogramKeys returns m's keys in sorted order for deterministic output.
+func sortedHistogramKeys(m map[string]*Histogram) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// sortedCounterKeys returns m's keys in sorted order for deterministic output.
+func sortedCounterKeys(m map[string]int64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"net/http"
+	"strings"
+)
+
+// Policy selects the next backend to serve a request from the given pool.
+// Implementations must only consider backends that are Available; they may
+// assume the pool is non-empty, but not that any backend in it is Available.
+type Policy interface {
+	// Select returns the backend chosen to serve r, or nil if none is available.
+	Select(pool []*Backend, r *http.Request) *Backend
+}
+
+// aliveBackends returns the subset of pool that is currently Available
+// (alive, not draining, and not ejected by the circuit breaker).
+func aliveBackends(pool []*Backend) []*Backend {
+	alive := make([]*Backend, 0, len(pool))
+	for _, b := range pool {
+		if b.Available() {
+			alive = append(alive, b)
+		}
+	}
+	return alive
+}
+
+// RoundRobinPolicy cycles through alive backends in order.
+type RoundRobinPolicy struct {
+	pool *ServerPool
+}
+
+// NewRoundRobinPolicy creates a round-robin policy bound to pool.
+func NewRoundRobinPolicy(pool *ServerPool) *RoundRobinPolicy {
+	return &RoundRobinPolicy{pool: pool}
+}
+
+// Select returns the next alive backend using round-robin.
+func (p *RoundRobinPolicy) Select(pool []*Backend, r *http.Request) *Backend {
+	if len(pool) == 0 {
+		return nil
+	}
+	next := p.pool.NextIndex()
+	l := len(pool) + next
+
+	for i := next; i < l; i++ {
+		idx := i % len(pool)
+		if pool[idx].Available() {
+			if i != next {
+				p.pool.SetCurrent(uint64(idx))
+			}
+			return pool[idx]
+		}
+	}
+	return nil
+}
+
+// RandomPolicy picks a uniformly random alive backend on every request.
+type RandomPolicy struct{}
+
+// NewRandomPolicy creates a random-selection policy.
+func NewRandomPolicy() *RandomPolicy {
+	return &RandomPolicy{}
+}
+
+// Select returns a random alive backend.
+func (p *RandomPolicy) Select(pool []*Backend, r *http.Request) *Backend {
+	alive := aliveBackends(pool)
+	if len(alive) == 0 {
+		return nil
+	}
+	return alive[rand.Intn(len(alive))]
+}
+
+// LeastConnPolicy routes to the alive backend with the fewest active connections.
+type LeastConnPolicy struct{}
+
+// NewLeastConnPolicy creates a least-connections policy.
+func NewLeastConnPolicy() *LeastConnPolicy {
+	return &LeastConnPolicy{}
+}
+
+// Select returns the alive backend currently handling the fewest requests.
+func (p *LeastConnPolicy) Select(pool []*Backend, r *http.Request) *Backend {
+	var best *Backend
+	var bestConns int64 = -1
+
+	for _, b := range pool {
+		if !b.Available() {
+			continue
+		}
+		conns := b.ActiveConns()
+		if bestConns == -1 || conns < bestConns {
+			best = b
+			bestConns = conns
+		}
+	}
+	return best
+}
+
+// WeightedRoundRobinPolicy distributes requests across alive backends in
+// proportion to their configured Weight, using smooth weighted round-robin.
+type WeightedRoundRobinPolicy struct{}
+
+// NewWeightedRoundRobinPolicy creates a weighted round-robin policy.
+func NewWeightedRoundRobinPolicy() *WeightedRoundRobinPolicy {
+	return &WeightedRoundRobinPolicy{}
+}
+
+// Select returns the next alive backend per the smooth weighted round-robin
+// algorithm (as used by nginx): each backend's current weight is increased by
+// its configured weight every pick, the highest current weight is chosen,
+// and that backend's current weight is reduced by the total weight.
+func (p *WeightedRoundRobinPolicy) Select(pool []*Backend, r *http.Request) *Backend {
+	var best *Backend
+	var totalWeight int64
+
+	for _, b := range pool {
+		if !b.Available() {
+			continue
+		}
+		weight := b.Weight
+		if weight <= 0 {
+			weight = 1
+		}
+		totalWeight += int64(weight)
+
+		current := b.AddCurrentWeight(int64(weight))
+		if best == nil || current > best.CurrentWeight() {
+			best = b
+		}
+	}
+
+	if best == nil {
+		return nil
+	}
+	best.AddCurrentWeight(-totalWeight)
+	return best
+}
+
+// IPHashPolicy deterministically maps a client IP to a backend so that a
+// given client is pinned to the same backend for as long as it stays alive.
+type IPHashPolicy struct{}
+
+// NewIPHashPolicy creates a client-IP-hash policy.
+func NewIPHashPolicy() *IPHashPolicy {
+	return &IPHashPolicy{}
+}
+
+// Select hashes the client's IP (preferring X-Forwarded-For, falling back to
+// RemoteAddr) modulo the alive backends to pick a sticky backend.
+func (p *IPHashPolicy) Select(pool []*Backend, r *http.Request) *Backend {
+	alive := aliveBackends(pool)
+	if len(alive) == 0 {
+		return nil
+	}
+
+	key := clientIP(r)
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	idx := int(h.Sum32()) % len(alive)
+	if idx < 0 {
+		idx += len(alive)
+	}
+	return alive[idx]
+}
+
+// clientIP extracts the originating client IP for hashing, preferring the
+// first address in X-Forwarded-For and falling back to RemoteAddr's host
+// (without the port).
+func clientIP(r *http.Request) string {
+	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+		return strings.TrimSpace(strings.Split(xff, ",")[0])
+	}
+	return remoteIP(r)
+}
+
+// NewPolicy constructs a Policy by name, as selected via the -policy flag.
+// Supported names: "round-robin" (default), "random", "least-conn",
+// "weighted-round-robin", "ip-hash".
+func NewPolicy(name string, pool *ServerPool) (Policy, error) {
+	switch name {
+	case "", "round-robin":
+		return NewRoundRobinPolicy(pool), nil
+	case "random":
+		return NewRandomPolicy(), nil
+	case "least-conn":
+		return NewLeastConnPolicy(), nil
+	case "weighted-round-robin":
+		return NewWeightedRoundRobinPolicy(), nil
+	case "ip-hash":
+		return NewIPHashPolicy(), nil
+	default:
+		return nil, fmt.Errorf("unknown policy %q", name)
+	}
+}
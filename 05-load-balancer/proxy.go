@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// hopByHopHeaders are connection-scoped headers that must not be forwarded
+// between a client and an upstream, per RFC 7230 section 6.1.
+var hopByHopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Proxy-Connection",
+	"Te",
+	"Trailer",
+	"Trailers",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// stripHopByHopHeaders removes the standard hop-by-hop headers from header,
+// as well as any additional header named in that header's Connection value.
+func stripHopByHopHeaders(header http.Header) {
+	for _, name := range strings.Split(header.Get("Connection"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			header.Del(name)
+		}
+	}
+	for _, name := range hopByHopHeaders {
+		header.Del(name)
+	}
+}
+
+// appendForwardedFor appends clientIP to any existing X-Forwarded-For chain
+// instead of overwriting it, so a request already proxied upstream keeps its
+// full chain of hops.
+func appendForwardedFor(header http.Header, clientIP string) {
+	if clientIP == "" {
+		return
+	}
+	if existing := header.Get("X-Forwarded-For"); existing != "" {
+		header.Set("X-Forwarded-For", existing+", "+clientIP)
+	} else {
+		header.Set("X-Forwarded-For", clientIP)
+	}
+}
+
+// remoteIP returns r's immediate peer address with any port stripped, for
+// use in X-Forwarded-For and request logging (as opposed to clientIP in
+// policy.go, which prefers an existing X-Forwarded-For for sticky hashing).
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// isWebSocketUpgrade reports whether r is an HTTP Upgrade request for a
+// WebSocket connection.
+func isWebSocketUpgrade(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Upgrade"), "websocket") &&
+		strings.Contains(strings.ToLower(r.Header.Get("Connection")), "upgrade")
+}
+
+// isEventStream reports whether resp is a Server-Sent Events stream that
+// must be flushed to the client as it arrives rather than buffered.
+func isEventStream(resp *http.Response) bool {
+	return strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream")
+}
+
+// proxyWebSocketRequest hijacks the client connection and splices it
+// directly to the backend's TCP connection, since WebSocket's persistent,
+// bidirectional stream can't be represented as a single http.Response.
+func proxyWebSocketRequest(w http.ResponseWriter, r *http.Request, peer *Backend) {
+	start := time.Now()
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "WebSocket upgrade not supported", http.StatusInternalServerError)
+		peer.RecordOutcome(false, time.Since(start))
+		return
+	}
+
+	backendConn, err := net.Dial("tcp", peer.URL.Host)
+	if err != nil {
+		http.Error(w, "Bad Gateway", http.StatusBadGateway)
+		peer.RecordOutcome(false, time.Since(start))
+		logRequest(RequestLogEntry{
+			Backend:       peer.URL.String(),
+			Method:        r.Method,
+			Path:          r.URL.Path,
+			Status:        http.StatusBadGateway,
+			ClientIP:      remoteIP(r),
+			UpstreamError: fmt.Sprintf("dialing backend for WebSocket upgrade: %v", err),
+		})
+		return
+	}
+	defer backendConn.Close()
+
+	stripHopByHopHeaders(r.Header)
+	r.Header.Set("Connection", "Upgrade")
+	r.Header.Set("Upgrade", "websocket")
+	appendForwardedFor(r.Header, remoteIP(r))
+
+	if err := r.Write(backendConn); err != nil {
+		http.Error(w, "Bad Gateway", http.StatusBadGateway)
+		peer.RecordOutcome(false, time.Since(start))
+		logRequest(RequestLogEntry{
+			Backend:       peer.URL.String(),
+			Method:        r.Method,
+			Path:          r.URL.Path,
+			Status:        http.StatusBadGateway,
+			ClientIP:      remoteIP(r),
+			UpstreamError: fmt.Sprintf("forwarding WebSocket upgrade: %v", err),
+		})
+		return
+	}
+
+	clientConn, clientBuf, err := hijacker.Hijack()
+	if err != nil {
+		peer.RecordOutcome(false, time.Since(start))
+		logRequest(RequestLogEntry{
+			Backend:       peer.URL.String(),
+			Method:        r.Method,
+			Path:          r.URL.Path,
+			ClientIP:      remoteIP(r),
+			UpstreamError: fmt.Sprintf("hijacking client connection for WebSocket upgrade: %v", err),
+		})
+		return
+	}
+	defer clientConn.Close()
+
+	// The upgrade succeeded and the connection is now spliced through; record
+	// it as a successful outcome so the circuit breaker and passive health
+	// counters see WebSocket traffic too. There's no further per-request
+	// outcome to report once the raw byte stream takes over.
+	peer.RecordOutcome(true, time.Since(start))
+
+	// Flush anything the hijack's buffered reader already consumed from the
+	// client before splicing the raw connections together.
+	if clientBuf.Reader.Buffered() > 0 {
+		if _, err := io.CopyN(backendConn, clientBuf.Reader, int64(clientBuf.Reader.Buffered())); err != nil {
+			return
+		}
+	}
+
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(backendConn, clientConn)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(clientConn, backendConn)
+		done <- struct{}{}
+	}()
+	<-done
+}
+
+// streamResponse copies resp's body to w, flushing after every chunk for
+// streaming content types like SSE instead of buffering the whole body.
+func streamResponse(w http.ResponseWriter, resp *http.Response) error {
+	flusher, canFlush := w.(http.Flusher)
+	buf := make([]byte, 4096)
+
+	for {
+		n, err := resp.Body.Read(buf)
+		if n > 0 {
+			if _, werr := w.Write(buf[:n]); werr != nil {
+				return werr
+			}
+			if canFlush {
+				flusher.Flush()
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
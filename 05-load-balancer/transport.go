@@ -0,0 +1,28 @@
+package main
+
+import "net/http"
+
+// Transport abstracts how a proxied request is actually sent to a backend,
+// so the load balancer can speak protocols other than plain HTTP (see
+// FastCGITransport) behind the same lb() code path.
+type Transport interface {
+	// RoundTrip sends req to the backend and returns its response, the way
+	// http.RoundTripper does for a single backend rather than a whole client.
+	RoundTrip(req *http.Request) (*http.Response, error)
+}
+
+// HTTPTransport is the original transport: it forwards the request as-is
+// over a plain http.Client.
+type HTTPTransport struct {
+	Client *http.Client
+}
+
+// NewHTTPTransport creates an HTTPTransport using client for outgoing requests.
+func NewHTTPTransport(client *http.Client) *HTTPTransport {
+	return &HTTPTransport{Client: client}
+}
+
+// RoundTrip sends req via the underlying http.Client.
+func (t *HTTPTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.Client.Do(req)
+}